@@ -0,0 +1,47 @@
+package outbound
+
+import (
+	"fmt"
+
+	"github.com/Dreamacro/clash/common/structure"
+	C "github.com/Dreamacro/clash/constant"
+)
+
+var proxyDecoder = structure.NewDecoder(structure.Option{TagName: "proxy", WeaklyTypedInput: true})
+
+// ParseProxy turns a single parsed YAML proxy mapping into a C.Proxy,
+// dispatching on its `type` field.
+func ParseProxy(mapping map[string]interface{}) (C.Proxy, error) {
+	proxyType, existed := mapping["type"].(string)
+	if !existed {
+		return nil, fmt.Errorf("missing type")
+	}
+
+	var (
+		proxy C.Proxy
+		err   error
+	)
+
+	switch proxyType {
+	case "vmess":
+		vmessOption := &VmessOption{}
+		if err = proxyDecoder.Decode(mapping, vmessOption); err != nil {
+			break
+		}
+		proxy, err = NewVmess(*vmessOption)
+	case "ssh":
+		sshOption := &SSHOption{}
+		if err = proxyDecoder.Decode(mapping, sshOption); err != nil {
+			break
+		}
+		proxy, err = NewSSH(*sshOption)
+	default:
+		return nil, fmt.Errorf("unsupport proxy type: %s", proxyType)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return proxy, nil
+}
@@ -0,0 +1,210 @@
+package outbound
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Dreamacro/clash/component/dialer"
+	C "github.com/Dreamacro/clash/constant"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const sshKeepAliveInterval = 30 * time.Second
+
+type SSH struct {
+	*Base
+	option SSHOption
+
+	mu     sync.Mutex
+	client *ssh.Client
+}
+
+type SSHOption struct {
+	Name                 string   `proxy:"name"`
+	Server               string   `proxy:"server"`
+	Port                 int      `proxy:"port"`
+	UserName             string   `proxy:"username"`
+	Password             string   `proxy:"password,omitempty"`
+	PrivateKey           string   `proxy:"private-key,omitempty"`
+	PrivateKeyPassphrase string   `proxy:"private-key-passphrase,omitempty"`
+	HostKey              []string `proxy:"host-key,omitempty"`
+}
+
+func (s *SSH) DialContext(ctx context.Context, metadata *C.Metadata) (C.Conn, error) {
+	client, err := s.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := client.Dial("tcp", metadata.RemoteAddress())
+	if err != nil {
+		s.resetIfCurrent(client)
+		return nil, fmt.Errorf("%s connect error: %w", s.server(), err)
+	}
+
+	return newConn(c, s), nil
+}
+
+func (s *SSH) DialUDP(metadata *C.Metadata) (C.PacketConn, error) {
+	return nil, errors.New("ssh doesn't support udp")
+}
+
+// dial returns the shared *ssh.Client, establishing and caching a new one if
+// none exists yet (first use, or after a previous failure reset it).
+func (s *SSH) dial(ctx context.Context) (*ssh.Client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client != nil {
+		return s.client, nil
+	}
+
+	config, err := s.clientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", s.server())
+	if err != nil {
+		return nil, fmt.Errorf("%s connect error: %w", s.server(), err)
+	}
+	tcpKeepAlive(conn)
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, s.server(), config)
+	if err != nil {
+		return nil, fmt.Errorf("ssh handshake error: %w", err)
+	}
+
+	client := ssh.NewClient(sshConn, chans, reqs)
+	s.client = client
+	go s.keepAlive(client)
+	return client, nil
+}
+
+func (s *SSH) server() string {
+	return net.JoinHostPort(s.option.Server, strconv.Itoa(s.option.Port))
+}
+
+// keepAlive sends a keepalive@clash request every 30s until client closes or
+// stops responding, at which point it resets the cached client so the next
+// DialContext reconnects.
+func (s *SSH) keepAlive(client *ssh.Client) {
+	ticker := time.NewTicker(sshKeepAliveInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if _, _, err := client.SendRequest("keepalive@clash", true, nil); err != nil {
+			s.resetIfCurrent(client)
+			return
+		}
+	}
+}
+
+// resetIfCurrent closes and clears s.client, but only if it is still client —
+// a keepalive goroutine for a connection that's already been superseded by a
+// reconnect must not tear down the new, healthy one.
+func (s *SSH) resetIfCurrent(client *ssh.Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.client != client {
+		return
+	}
+
+	s.client.Close()
+	s.client = nil
+}
+
+func (s *SSH) clientConfig() (*ssh.ClientConfig, error) {
+	var auths []ssh.AuthMethod
+
+	if s.option.Password != "" {
+		auths = append(auths, ssh.Password(s.option.Password))
+	}
+
+	if s.option.PrivateKey != "" {
+		signer, err := parsePrivateKey(s.option.PrivateKey, s.option.PrivateKeyPassphrase)
+		if err != nil {
+			return nil, err
+		}
+		auths = append(auths, ssh.PublicKeys(signer))
+	}
+
+	if len(auths) == 0 {
+		return nil, errors.New("ssh: one of password or private-key must be configured")
+	}
+
+	hostKeyCallback, err := s.hostKeyCallback()
+	if err != nil {
+		return nil, err
+	}
+
+	return &ssh.ClientConfig{
+		User:            s.option.UserName,
+		Auth:            auths,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         tcpTimeout,
+	}, nil
+}
+
+// hostKeyCallback pins the server to the configured host keys, each given as
+// a full public key in authorized_keys format (not a fingerprint), or
+// accepts any host key when none are configured.
+func (s *SSH) hostKeyCallback() (ssh.HostKeyCallback, error) {
+	if len(s.option.HostKey) == 0 {
+		return ssh.InsecureIgnoreHostKey(), nil
+	}
+
+	keys := make([]ssh.PublicKey, 0, len(s.option.HostKey))
+	for _, raw := range s.option.HostKey {
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(raw))
+		if err != nil {
+			return nil, fmt.Errorf("invalid host-key %q: %w", raw, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		for _, k := range keys {
+			if ssh.FixedHostKey(k)(hostname, remote, key) == nil {
+				return nil
+			}
+		}
+		return fmt.Errorf("ssh: host key mismatch for %s", hostname)
+	}, nil
+}
+
+// parsePrivateKey accepts either a PEM-encoded key or a filesystem path to one.
+func parsePrivateKey(pathOrPEM, passphrase string) (ssh.Signer, error) {
+	pemBytes := []byte(pathOrPEM)
+	if _, err := os.Stat(pathOrPEM); err == nil {
+		pemBytes, err = ioutil.ReadFile(pathOrPEM)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if passphrase != "" {
+		return ssh.ParsePrivateKeyWithPassphrase(pemBytes, []byte(passphrase))
+	}
+	return ssh.ParsePrivateKey(pemBytes)
+}
+
+func NewSSH(option SSHOption) (*SSH, error) {
+	return &SSH{
+		Base: &Base{
+			name: option.Name,
+			tp:   C.Ssh,
+			udp:  false,
+		},
+		option: option,
+	}, nil
+}
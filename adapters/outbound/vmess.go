@@ -21,18 +21,22 @@ type Vmess struct {
 }
 
 type VmessOption struct {
-	Name           string            `proxy:"name"`
-	Server         string            `proxy:"server"`
-	Port           int               `proxy:"port"`
-	UUID           string            `proxy:"uuid"`
-	AlterID        int               `proxy:"alterId"`
-	Cipher         string            `proxy:"cipher"`
-	TLS            bool              `proxy:"tls,omitempty"`
-	UDP            bool              `proxy:"udp,omitempty"`
-	Network        string            `proxy:"network,omitempty"`
-	WSPath         string            `proxy:"ws-path,omitempty"`
-	WSHeaders      map[string]string `proxy:"ws-headers,omitempty"`
-	SkipCertVerify bool              `proxy:"skip-cert-verify,omitempty"`
+	Name            string            `proxy:"name"`
+	Server          string            `proxy:"server"`
+	Port            int               `proxy:"port"`
+	UUID            string            `proxy:"uuid"`
+	AlterID         int               `proxy:"alterId"`
+	Cipher          string            `proxy:"cipher"`
+	TLS             bool              `proxy:"tls,omitempty"`
+	UDP             bool              `proxy:"udp,omitempty"`
+	Network         string            `proxy:"network,omitempty"`
+	WSPath          string            `proxy:"ws-path,omitempty"`
+	WSHeaders       map[string]string `proxy:"ws-headers,omitempty"`
+	GrpcServiceName string            `proxy:"grpc-service-name,omitempty"`
+	GrpcMode        string            `proxy:"grpc-mode,omitempty"`
+	H2Path          string            `proxy:"h2-path,omitempty"`
+	H2Host          []string          `proxy:"h2-host,omitempty"`
+	SkipCertVerify  bool              `proxy:"skip-cert-verify,omitempty"`
 }
 
 func (v *Vmess) DialContext(ctx context.Context, metadata *C.Metadata) (C.Conn, error) {
@@ -71,6 +75,26 @@ func (v *Vmess) DialUDP(metadata *C.Metadata) (C.PacketConn, error) {
 
 func NewVmess(option VmessOption) (*Vmess, error) {
 	security := strings.ToLower(option.Cipher)
+
+	switch option.Network {
+	case "", "tcp", "ws", "grpc", "h2":
+	default:
+		return nil, fmt.Errorf("unsupported network %q for vmess", option.Network)
+	}
+
+	if option.Network == "grpc" {
+		if option.GrpcMode == "" {
+			option.GrpcMode = "gun"
+		}
+		// multi mode multiplexes several logical streams over one gRPC
+		// stream (PacketUp/PacketDown); component/vmess only implements the
+		// single-stream gun transport, so reject multi here instead of
+		// silently running it as gun.
+		if option.GrpcMode != "gun" {
+			return nil, fmt.Errorf("unsupported grpc-mode %q for vmess", option.GrpcMode)
+		}
+	}
+
 	client, err := vmess.NewClient(vmess.Config{
 		UUID:             option.UUID,
 		AlterID:          uint16(option.AlterID),
@@ -81,6 +105,10 @@ func NewVmess(option VmessOption) (*Vmess, error) {
 		NetWork:          option.Network,
 		WebSocketPath:    option.WSPath,
 		WebSocketHeaders: option.WSHeaders,
+		GrpcServiceName:  option.GrpcServiceName,
+		GrpcMode:         option.GrpcMode,
+		H2Path:           option.H2Path,
+		H2Host:           option.H2Host,
 		SkipCertVerify:   option.SkipCertVerify,
 		SessionCache:     getClientSessionCache(),
 	})
@@ -0,0 +1,215 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+type dohCacheEntry struct {
+	ip      net.IP
+	expires time.Time
+}
+
+// DoHVehicle fetches a provider's subscription body over HTTP(S) while
+// resolving the URL's host through a DNS-over-HTTPS resolver, so the initial
+// pull cannot be poisoned by the system resolver or blocked by DNS
+// hijacking. `bootstrapIP`, when set, is used to dial the DoH server itself,
+// so resolving its hostname doesn't depend on the system resolver either.
+type DoHVehicle struct {
+	url         string
+	path        string
+	dohServer   string
+	bootstrapIP string
+
+	mu    sync.Mutex
+	cache map[string]dohCacheEntry
+
+	client *http.Client
+}
+
+func (d *DoHVehicle) Type() VehicleType {
+	return HTTP
+}
+
+func (d *DoHVehicle) Path() string {
+	return d.path
+}
+
+func (d *DoHVehicle) Read() ([]byte, http.Header, error) {
+	resp, err := d.client.Get(d.url)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return buf, resp.Header, nil
+}
+
+// NewDoHVehicle creates a DoHVehicle that fetches url and caches it at path,
+// resolving url's host via the DoH server at dohServer (e.g.
+// "https://1.1.1.1/dns-query"). bootstrapIP may be empty.
+func NewDoHVehicle(url, path, dohServer, bootstrapIP string) *DoHVehicle {
+	d := &DoHVehicle{
+		url:         url,
+		path:        path,
+		dohServer:   dohServer,
+		bootstrapIP: bootstrapIP,
+		cache:       map[string]dohCacheEntry{},
+	}
+
+	d.client = &http.Client{
+		Timeout: 20 * time.Second,
+		Transport: &http.Transport{
+			DialContext: d.dialContext,
+		},
+	}
+
+	return d
+}
+
+// dialContext resolves address's host via DoH (unless it's already an IP)
+// before handing off to a plain net.Dialer.
+func (d *DoHVehicle) dialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		ip, err = d.resolve(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return (&net.Dialer{}).DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+}
+
+func (d *DoHVehicle) resolve(ctx context.Context, host string) (net.IP, error) {
+	if ip, ok := d.cached(host); ok {
+		return ip, nil
+	}
+
+	ip, ttl, err := d.query(ctx, host, dnsmessage.TypeA)
+	if err == nil && ip == nil {
+		ip, ttl, err = d.query(ctx, host, dnsmessage.TypeAAAA)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ip == nil {
+		return nil, fmt.Errorf("doh: no address record for %s", host)
+	}
+
+	d.store(host, ip, ttl)
+	return ip, nil
+}
+
+func (d *DoHVehicle) cached(host string) (net.IP, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	entry, ok := d.cache[host]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false
+	}
+	return entry.ip, true
+}
+
+func (d *DoHVehicle) store(host string, ip net.IP, ttl time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.cache[host] = dohCacheEntry{ip: ip, expires: time.Now().Add(ttl)}
+}
+
+// query performs a single DoH lookup against d.dohServer for host/qtype and
+// returns the first matching answer's address and TTL.
+func (d *DoHVehicle) query(ctx context.Context, host string, qtype dnsmessage.Type) (net.IP, time.Duration, error) {
+	name, err := dnsmessage.NewName(host + ".")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{RecursionDesired: true},
+		Questions: []dnsmessage.Question{{
+			Name:  name,
+			Type:  qtype,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+
+	packed, err := msg.Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.dohServer, bytes.NewReader(packed))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+
+	resp, err := d.bootstrapClient().Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var reply dnsmessage.Message
+	if err := reply.Unpack(body); err != nil {
+		return nil, 0, err
+	}
+
+	for _, answer := range reply.Answers {
+		switch res := answer.Body.(type) {
+		case *dnsmessage.AResource:
+			return net.IP(res.A[:]), time.Duration(answer.Header.TTL) * time.Second, nil
+		case *dnsmessage.AAAAResource:
+			return net.IP(res.AAAA[:]), time.Duration(answer.Header.TTL) * time.Second, nil
+		}
+	}
+
+	return nil, 0, nil
+}
+
+// bootstrapClient dials the DoH server itself via bootstrapIP when set, so
+// even resolving the DoH server's own hostname avoids the system resolver.
+func (d *DoHVehicle) bootstrapClient() *http.Client {
+	if d.bootstrapIP == "" {
+		return http.DefaultClient
+	}
+
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+				_, port, err := net.SplitHostPort(address)
+				if err != nil {
+					return nil, err
+				}
+				return (&net.Dialer{}).DialContext(ctx, network, net.JoinHostPort(d.bootstrapIP, port))
+			},
+		},
+	}
+}
@@ -0,0 +1,18 @@
+package provider
+
+import C "github.com/Dreamacro/clash/constant"
+
+// ProxiesFromProviders flattens providers into the single proxy list a
+// `select`/`url-test`/`fallback` group dials through, skipping any provider
+// that has gone Unhealthy (e.g. a subscription past its soft quota) so the
+// group never selects a proxy it backs.
+func ProxiesFromProviders(providers []ProxyProvider) []C.Proxy {
+	var proxies []C.Proxy
+	for _, p := range providers {
+		if p.Unhealthy() {
+			continue
+		}
+		proxies = append(proxies, p.Proxies()...)
+	}
+	return proxies
+}
@@ -0,0 +1,291 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	C "github.com/Dreamacro/clash/constant"
+	"github.com/Dreamacro/clash/log"
+)
+
+const (
+	defaultURLTestTimeout = time.Second * 5
+)
+
+// statusRange is a single inclusive [lo, hi] status-code range.
+type statusRange struct {
+	lo, hi uint16
+}
+
+// StatusChecker reports whether an HTTP status code is considered "healthy"
+// for a proxy-provider's health check. It is built from an expected-status
+// expression such as "200/204/301-302/400-499" and answers Check in
+// O(log n) via binary search over the sorted, non-overlapping ranges.
+type StatusChecker struct {
+	ranges []statusRange
+}
+
+// NewStatusChecker parses a comma-or-slash separated list of status tokens,
+// where each token is either `N` or `N-M`. An empty expression falls back to
+// the conventional "2xx/3xx is healthy" rule.
+func NewStatusChecker(expected string) (*StatusChecker, error) {
+	if expected == "" {
+		return &StatusChecker{}, nil
+	}
+
+	tokens := strings.FieldsFunc(expected, func(r rune) bool {
+		return r == ',' || r == '/'
+	})
+
+	ranges := make([]statusRange, 0, len(tokens))
+	for _, token := range tokens {
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+
+		parts := strings.SplitN(token, "-", 2)
+		lo, err := strconv.ParseUint(parts[0], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expected-status token %q: %w", token, err)
+		}
+
+		hi := lo
+		if len(parts) == 2 {
+			hi, err = strconv.ParseUint(parts[1], 10, 16)
+			if err != nil {
+				return nil, fmt.Errorf("invalid expected-status token %q: %w", token, err)
+			}
+		}
+
+		if hi < lo {
+			return nil, fmt.Errorf("invalid expected-status token %q: range is reversed", token)
+		}
+
+		ranges = append(ranges, statusRange{lo: uint16(lo), hi: uint16(hi)})
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].lo < ranges[j].lo })
+
+	// Merge overlapping ranges so hi grows monotonically with index; Check
+	// relies on that invariant to binary-search.
+	merged := ranges[:0]
+	for _, r := range ranges {
+		if len(merged) > 0 && r.lo <= merged[len(merged)-1].hi {
+			if r.hi > merged[len(merged)-1].hi {
+				merged[len(merged)-1].hi = r.hi
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+
+	return &StatusChecker{ranges: merged}, nil
+}
+
+// Check reports whether code falls into one of the checker's ranges. With no
+// ranges configured it falls back to treating any 2xx/3xx as healthy.
+func (sc *StatusChecker) Check(code uint16) bool {
+	if sc == nil || len(sc.ranges) == 0 {
+		return code >= 200 && code < 400
+	}
+
+	i := sort.Search(len(sc.ranges), func(i int) bool {
+		return sc.ranges[i].hi >= code
+	})
+	return i < len(sc.ranges) && sc.ranges[i].lo <= code
+}
+
+// HealthCheckOption configures a HealthCheck.
+type HealthCheckOption struct {
+	URL            string
+	Interval       uint
+	TestURL        string
+	TestMethod     string
+	ExpectedStatus *StatusChecker
+}
+
+// HealthCheck periodically probes every proxy in a ProxySetProvider. When
+// TestURL is configured it issues an HTTP request through each proxy and
+// only considers the proxy alive if the response status code falls into one
+// of the ExpectedStatus ranges (any 2xx/3xx when ExpectedStatus is unset);
+// without a TestURL it falls back to a plain latency test.
+type HealthCheck struct {
+	url            string
+	testURL        string
+	testMethod     string
+	expectedStatus *StatusChecker
+	proxies        []C.Proxy
+	interval       uint
+	done           chan struct{}
+
+	mu         sync.Mutex
+	lastStatus map[string]uint16
+}
+
+func (hc *HealthCheck) process() {
+	ticker := time.NewTicker(time.Duration(hc.interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			hc.check()
+		case <-hc.done:
+			return
+		}
+	}
+}
+
+func (hc *HealthCheck) setProxy(proxies []C.Proxy) {
+	hc.proxies = proxies
+}
+
+func (hc *HealthCheck) auto() bool {
+	return hc.interval != 0
+}
+
+// statusCode returns the last observed status code for the named proxy.
+func (hc *HealthCheck) statusCode(name string) (uint16, bool) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	code, ok := hc.lastStatus[name]
+	return code, ok
+}
+
+func (hc *HealthCheck) setStatusCode(name string, code uint16) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	hc.lastStatus[name] = code
+}
+
+func (hc *HealthCheck) check() {
+	if len(hc.proxies) == 0 {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, proxy := range hc.proxies {
+		p := proxy
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			hc.checkOne(p)
+		}()
+	}
+	wg.Wait()
+}
+
+func (hc *HealthCheck) checkOne(proxy C.Proxy) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultURLTestTimeout)
+	defer cancel()
+
+	if hc.testURL == "" {
+		if _, err := proxy.URLTest(ctx, hc.url); err != nil {
+			log.Debugln("[Health Check] %s is not alive: %s", proxy.Name(), err.Error())
+		}
+		return
+	}
+
+	code, err := hc.probe(ctx, proxy)
+	if err != nil {
+		log.Debugln("[Health Check] %s status probe failed: %s", proxy.Name(), err.Error())
+		hc.failProxy(proxy)
+		return
+	}
+
+	hc.setStatusCode(proxy.Name(), code)
+	if !hc.expectedStatus.Check(code) {
+		log.Warnln("[Health Check] %s returned unexpected status %d, marking unhealthy", proxy.Name(), code)
+		hc.failProxy(proxy)
+		return
+	}
+
+	// status is within the expected ranges: still record a real latency
+	// sample so Fallback/URLTest groups, which read a proxy's own alive/delay
+	// history rather than this package's dashboard status, see it as up.
+	if _, err := proxy.URLTest(ctx, hc.url); err != nil {
+		log.Debugln("[Health Check] %s is not alive: %s", proxy.Name(), err.Error())
+	}
+}
+
+// failProxy forces proxy's own alive/delay bookkeeping to record a failure
+// by running its URLTest against an already-cancelled context, so
+// Fallback/URLTest groups stop selecting it even though this package's
+// health check, not the proxy's usual dialer, is what detected the problem.
+func (hc *HealthCheck) failProxy(proxy C.Proxy) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _ = proxy.URLTest(ctx, hc.url)
+}
+
+// probe issues a single HTTP request for hc.testURL through proxy and
+// returns the response status code.
+func (hc *HealthCheck) probe(ctx context.Context, proxy C.Proxy) (uint16, error) {
+	u, err := url.Parse(hc.testURL)
+	if err != nil {
+		return 0, err
+	}
+
+	method := hc.testMethod
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	client := &http.Client{
+		Timeout: defaultURLTestTimeout,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+				host, port, err := net.SplitHostPort(address)
+				if err != nil {
+					return nil, err
+				}
+				return proxy.DialContext(ctx, &C.Metadata{
+					Host:    host,
+					DstPort: port,
+				})
+			},
+		},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	return uint16(resp.StatusCode), nil
+}
+
+func (hc *HealthCheck) close() {
+	hc.done <- struct{}{}
+}
+
+// NewHealthCheck creates a HealthCheck from a HealthCheckOption.
+func NewHealthCheck(proxies []C.Proxy, opt HealthCheckOption) *HealthCheck {
+	return &HealthCheck{
+		proxies:        proxies,
+		url:            opt.URL,
+		testURL:        opt.TestURL,
+		testMethod:     opt.TestMethod,
+		expectedStatus: opt.ExpectedStatus,
+		interval:       opt.Interval,
+		done:           make(chan struct{}, 1),
+		lastStatus:     map[string]uint16{},
+	}
+}
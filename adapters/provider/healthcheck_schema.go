@@ -0,0 +1,44 @@
+package provider
+
+import "fmt"
+
+// healthCheckSchema is the `health-check:` sub-block of a proxy-provider
+// config. expected-status is parsed into a StatusChecker here so an invalid
+// expression fails at config-load time rather than at first probe.
+type healthCheckSchema struct {
+	URL            string `provider:"url"`
+	Interval       int    `provider:"interval"`
+	TestURL        string `provider:"test-url,omitempty"`
+	TestMethod     string `provider:"test-method,omitempty"`
+	ExpectedStatus string `provider:"expected-status,omitempty"`
+}
+
+func newHealthCheck(schema *healthCheckSchema) (*HealthCheck, error) {
+	if schema == nil {
+		schema = &healthCheckSchema{}
+	}
+
+	expectedStatus, err := NewStatusChecker(schema.ExpectedStatus)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expected-status: %w", err)
+	}
+
+	// A bare expected-status with no test-url would otherwise silently fall
+	// back to the old plain-latency check (checkOne only probes when
+	// testURL != ""), so the status-code check the user just configured
+	// would never run. Default test-url to the health check's own url
+	// instead of erroring: it's the same target the plain latency check
+	// already hits.
+	testURL := schema.TestURL
+	if testURL == "" && schema.ExpectedStatus != "" {
+		testURL = schema.URL
+	}
+
+	return NewHealthCheck(nil, HealthCheckOption{
+		URL:            schema.URL,
+		Interval:       uint(schema.Interval),
+		TestURL:        testURL,
+		TestMethod:     schema.TestMethod,
+		ExpectedStatus: expectedStatus,
+	}), nil
+}
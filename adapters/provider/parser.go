@@ -0,0 +1,87 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Dreamacro/clash/common/structure"
+)
+
+// dohSchema is the `doh:` sub-block of a `type: http` proxy-provider vehicle,
+// switching the initial/periodic pull to a DoH-resolving Vehicle so it can't
+// be poisoned by the system resolver or blocked by DNS hijacking.
+type dohSchema struct {
+	Server      string `provider:"server"`
+	BootstrapIP string `provider:"bootstrap-ip,omitempty"`
+}
+
+type proxyProviderSchema struct {
+	Type          string             `provider:"type"`
+	URL           string             `provider:"url,omitempty"`
+	Path          string             `provider:"path"`
+	Interval      int                `provider:"interval,omitempty"`
+	Filter        string             `provider:"filter,omitempty"`
+	ExcludeFilter string             `provider:"exclude-filter,omitempty"`
+	QuotaRatio    float64            `provider:"quota-ratio,omitempty"`
+	DoH           *dohSchema         `provider:"doh,omitempty"`
+	HealthCheck   *healthCheckSchema `provider:"health-check"`
+}
+
+var proxyProviderDecoder = structure.NewDecoder(structure.Option{TagName: "provider", WeaklyTypedInput: true})
+
+// NewProxyProvider builds a ProxySetProvider from a parsed proxy-provider
+// config mapping. filter/exclude-filter are compiled here so an invalid
+// regex fails at config-load time rather than at first use. A `doh:`
+// sub-block under a `type: http` vehicle transparently switches Initial/pull
+// to a DoHVehicle instead of a plain HTTPVehicle.
+func NewProxyProvider(name string, mapping map[string]interface{}) (*ProxySetProvider, error) {
+	schema := &proxyProviderSchema{}
+	if err := proxyProviderDecoder.Decode(mapping, schema); err != nil {
+		return nil, err
+	}
+
+	vehicle, err := newVehicle(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	filterRegx, err := compileFilter(schema.Filter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+
+	excludeFilterRegx, err := compileFilter(schema.ExcludeFilter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid exclude-filter: %w", err)
+	}
+
+	if schema.QuotaRatio < 0 || schema.QuotaRatio > 1 {
+		return nil, fmt.Errorf("invalid quota-ratio: %v, must be within [0, 1]", schema.QuotaRatio)
+	}
+
+	hc, err := newHealthCheck(schema.HealthCheck)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := time.Duration(schema.Interval) * time.Second
+	return NewProxySetProvider(name, interval, vehicle, hc, schema.QuotaRatio, filterRegx, excludeFilterRegx), nil
+}
+
+func newVehicle(schema *proxyProviderSchema) (Vehicle, error) {
+	switch schema.Type {
+	case "file":
+		return NewFileVehicle(schema.Path), nil
+	case "http":
+		if schema.URL == "" {
+			return nil, errors.New("proxy-provider url is required for type: http")
+		}
+		if schema.DoH != nil {
+			return NewDoHVehicle(schema.URL, schema.Path, schema.DoH.Server, schema.DoH.BootstrapIP), nil
+		}
+		return NewHTTPVehicle(schema.URL, schema.Path), nil
+	default:
+		return nil, fmt.Errorf("unsupported vehicle type: %s", schema.Type)
+	}
+}
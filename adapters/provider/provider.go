@@ -7,7 +7,9 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
+	"regexp"
 	"time"
 
 	"github.com/Dreamacro/clash/adapters/outbound"
@@ -59,6 +61,13 @@ type ProxyProvider interface {
 	Proxies() []C.Proxy
 	HealthCheck()
 	Update() error
+	// Unhealthy reports whether the provider's subscription has exceeded its
+	// soft quota, so groups such as Fallback/URLTest can skip it entirely.
+	Unhealthy() bool
+	// ProxiesWithFilter returns the subset of Proxies() whose name matches re.
+	// A nil re returns the same slice as Proxies(), letting a single provider
+	// back several proxy groups each with their own `filter`.
+	ProxiesWithFilter(re *regexp.Regexp) []C.Proxy
 }
 
 type ProxySchema struct {
@@ -66,23 +75,94 @@ type ProxySchema struct {
 }
 
 type ProxySetProvider struct {
-	name        string
-	vehicle     Vehicle
-	hash        [16]byte
-	proxies     []C.Proxy
-	healthCheck *HealthCheck
-	ticker      *time.Ticker
-	updatedAt   *time.Time
+	name              string
+	vehicle           Vehicle
+	hash              [16]byte
+	proxies           []C.Proxy
+	healthCheck       *HealthCheck
+	ticker            *time.Ticker
+	updatedAt         *time.Time
+	subscriptionInfo  *SubscriptionInfo
+	quotaRatio        float64
+	unhealthy         bool
+	filterRegx        *regexp.Regexp
+	excludeFilterRegx *regexp.Regexp
 }
 
 func (pp *ProxySetProvider) MarshalJSON() ([]byte, error) {
-	return json.Marshal(map[string]interface{}{
+	fields := map[string]interface{}{
 		"name":        pp.Name(),
 		"type":        pp.Type().String(),
 		"vehicleType": pp.VehicleType().String(),
-		"proxies":     pp.Proxies(),
+		"proxies":     pp.proxiesWithStatus(),
 		"updatedAt":   pp.updatedAt,
-	})
+	}
+
+	if info := pp.subscriptionInfo; info != nil {
+		fields["upload"] = info.Upload
+		fields["download"] = info.Download
+		fields["total"] = info.Total
+		fields["expire"] = info.Expire
+	}
+
+	return json.Marshal(fields)
+}
+
+// Unhealthy reports whether the provider's subscription traffic has crossed
+// its configured soft-quota ratio.
+func (pp *ProxySetProvider) Unhealthy() bool {
+	return pp.unhealthy
+}
+
+// applySubscriptionInfo records the subscription metadata from a pull and,
+// when the provider is over its soft quota, marks it unhealthy and logs a
+// warning so Fallback/URLTest groups know to skip it.
+func (pp *ProxySetProvider) applySubscriptionInfo(header http.Header) {
+	info := parseSubscriptionInfo(header)
+	if info == nil {
+		return
+	}
+
+	pp.subscriptionInfo = info
+	pp.unhealthy = info.overQuota(pp.quotaRatio)
+	if pp.unhealthy {
+		log.Warnln("[Provider] %s is over its subscription quota (%d/%d bytes used)", pp.Name(), info.Upload+info.Download, info.Total)
+	}
+}
+
+// proxiesWithStatus annotates each proxy with the last status code observed
+// by the health check, so the dashboard can surface captive-portal/403-style
+// failures that a plain latency test would miss.
+func (pp *ProxySetProvider) proxiesWithStatus() []*proxyWithStatus {
+	proxies := pp.Proxies()
+	out := make([]*proxyWithStatus, 0, len(proxies))
+	for _, proxy := range proxies {
+		code, _ := pp.healthCheck.statusCode(proxy.Name())
+		out = append(out, &proxyWithStatus{proxy: proxy, statusCode: code})
+	}
+	return out
+}
+
+// proxyWithStatus wraps a C.Proxy so its MarshalJSON output gains a
+// `statusCode` field without disturbing the proxy's own JSON representation.
+type proxyWithStatus struct {
+	proxy      C.Proxy
+	statusCode uint16
+}
+
+func (p *proxyWithStatus) MarshalJSON() ([]byte, error) {
+	raw, err := json.Marshal(p.proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, err
+	}
+	fields["statusCode"] = p.statusCode
+
+	return json.Marshal(fields)
 }
 
 func (pp *ProxySetProvider) Name() string {
@@ -113,13 +193,16 @@ func (pp *ProxySetProvider) Destroy() error {
 
 func (pp *ProxySetProvider) Initial() error {
 	var buf []byte
+	var header http.Header
 	var err error
-	if stat, err := os.Stat(pp.vehicle.Path()); err == nil {
+
+	stat, statErr := os.Stat(pp.vehicle.Path())
+	if statErr == nil {
 		buf, err = ioutil.ReadFile(pp.vehicle.Path())
 		modTime := stat.ModTime()
 		pp.updatedAt = &modTime
 	} else {
-		buf, err = pp.vehicle.Read()
+		buf, header, err = pp.vehicle.Read()
 	}
 
 	if err != nil {
@@ -129,12 +212,14 @@ func (pp *ProxySetProvider) Initial() error {
 	proxies, err := pp.parse(buf)
 	if err != nil {
 		// parse local file error, fallback to remote
-		buf, err = pp.vehicle.Read()
+		buf, header, err = pp.vehicle.Read()
 		if err != nil {
 			return err
 		}
 	}
 
+	pp.applySubscriptionInfo(header)
+
 	if err := ioutil.WriteFile(pp.vehicle.Path(), buf, fileMode); err != nil {
 		return err
 	}
@@ -162,6 +247,23 @@ func (pp *ProxySetProvider) Proxies() []C.Proxy {
 	return pp.proxies
 }
 
+// ProxiesWithFilter returns the proxies whose name matches re, so a single
+// provider pull can back several `use:`-referencing proxy groups that each
+// apply their own `filter`.
+func (pp *ProxySetProvider) ProxiesWithFilter(re *regexp.Regexp) []C.Proxy {
+	if re == nil {
+		return pp.proxies
+	}
+
+	proxies := make([]C.Proxy, 0, len(pp.proxies))
+	for _, proxy := range pp.proxies {
+		if re.MatchString(proxy.Name()) {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
 func (pp *ProxySetProvider) pullLoop() {
 	for range pp.ticker.C {
 		if err := pp.pull(); err != nil {
@@ -171,11 +273,13 @@ func (pp *ProxySetProvider) pullLoop() {
 }
 
 func (pp *ProxySetProvider) pull() error {
-	buf, err := pp.vehicle.Read()
+	buf, header, err := pp.vehicle.Read()
 	if err != nil {
 		return err
 	}
 
+	pp.applySubscriptionInfo(header)
+
 	now := time.Now()
 	hash := md5.Sum(buf)
 	if bytes.Equal(pp.hash[:], hash[:]) {
@@ -218,6 +322,14 @@ func (pp *ProxySetProvider) parse(buf []byte) ([]C.Proxy, error) {
 		if err != nil {
 			return nil, fmt.Errorf("Proxy %d error: %w", idx, err)
 		}
+
+		if pp.filterRegx != nil && !pp.filterRegx.MatchString(proxy.Name()) {
+			continue
+		}
+		if pp.excludeFilterRegx != nil && pp.excludeFilterRegx.MatchString(proxy.Name()) {
+			continue
+		}
+
 		proxies = append(proxies, proxy)
 	}
 
@@ -234,7 +346,14 @@ func (pp *ProxySetProvider) setProxies(proxies []C.Proxy) {
 	go pp.healthCheck.check()
 }
 
-func NewProxySetProvider(name string, interval time.Duration, vehicle Vehicle, hc *HealthCheck) *ProxySetProvider {
+// NewProxySetProvider creates a ProxySetProvider. quotaRatio is the
+// upload+download/total fraction past which the provider is marked unhealthy
+// (0 disables the soft-quota check). filterRegx/excludeFilterRegx, when
+// non-nil, restrict parse to proxies whose name matches/doesn't-match them;
+// callers must compile the `filter`/`exclude-filter` config fields before
+// calling this, so an invalid regex fails at config-load time rather than on
+// first use.
+func NewProxySetProvider(name string, interval time.Duration, vehicle Vehicle, hc *HealthCheck, quotaRatio float64, filterRegx, excludeFilterRegx *regexp.Regexp) *ProxySetProvider {
 	var ticker *time.Ticker
 	if interval != 0 {
 		ticker = time.NewTicker(interval)
@@ -245,11 +364,14 @@ func NewProxySetProvider(name string, interval time.Duration, vehicle Vehicle, h
 	}
 
 	return &ProxySetProvider{
-		name:        name,
-		vehicle:     vehicle,
-		proxies:     []C.Proxy{},
-		healthCheck: hc,
-		ticker:      ticker,
+		name:              name,
+		vehicle:           vehicle,
+		proxies:           []C.Proxy{},
+		healthCheck:       hc,
+		ticker:            ticker,
+		quotaRatio:        quotaRatio,
+		filterRegx:        filterRegx,
+		excludeFilterRegx: excludeFilterRegx,
 	}
 }
 
@@ -305,6 +427,27 @@ func (cp *CompatibleProvider) Proxies() []C.Proxy {
 	return cp.proxies
 }
 
+// Unhealthy is always false for CompatibleProvider: it has no vehicle and
+// therefore no subscription quota to exceed.
+func (cp *CompatibleProvider) Unhealthy() bool {
+	return false
+}
+
+// ProxiesWithFilter returns the proxies whose name matches re.
+func (cp *CompatibleProvider) ProxiesWithFilter(re *regexp.Regexp) []C.Proxy {
+	if re == nil {
+		return cp.proxies
+	}
+
+	proxies := make([]C.Proxy, 0, len(cp.proxies))
+	for _, proxy := range cp.proxies {
+		if re.MatchString(proxy.Name()) {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
 func NewCompatibleProvider(name string, proxies []C.Proxy, hc *HealthCheck) (*CompatibleProvider, error) {
 	if len(proxies) == 0 {
 		return nil, errors.New("Provider need one proxy at least")
@@ -0,0 +1,67 @@
+package provider
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// SubscriptionInfo is the traffic/quota metadata a provider reports over the
+// `subscription-userinfo` response header, e.g.
+// `upload=1234; download=5678; total=10737418240; expire=1654041600`.
+type SubscriptionInfo struct {
+	Upload   int64 `json:"upload"`
+	Download int64 `json:"download"`
+	Total    int64 `json:"total"`
+	Expire   int64 `json:"expire"`
+}
+
+// parseSubscriptionInfo extracts a SubscriptionInfo from a provider pull's
+// response headers. It returns nil if the header is absent.
+func parseSubscriptionInfo(header http.Header) *SubscriptionInfo {
+	raw := header.Get("subscription-userinfo")
+	if raw == "" {
+		return nil
+	}
+
+	info := &SubscriptionInfo{}
+	for _, field := range strings.Split(raw, ";") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		value, err := strconv.ParseInt(strings.TrimSpace(kv[1]), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		switch strings.ToLower(strings.TrimSpace(kv[0])) {
+		case "upload":
+			info.Upload = value
+		case "download":
+			info.Download = value
+		case "total":
+			info.Total = value
+		case "expire":
+			info.Expire = value
+		}
+	}
+
+	return info
+}
+
+// overQuota reports whether the subscription's used traffic has crossed
+// ratio of its total quota (ratio <= 0 disables the check).
+func (info *SubscriptionInfo) overQuota(ratio float64) bool {
+	if info == nil || ratio <= 0 || info.Total <= 0 {
+		return false
+	}
+
+	return float64(info.Upload+info.Download) > float64(info.Total)*ratio
+}
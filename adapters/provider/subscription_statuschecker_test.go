@@ -0,0 +1,96 @@
+package provider
+
+import "testing"
+
+func TestStatusCheckerDefault(t *testing.T) {
+	sc, err := NewStatusChecker("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, code := range []uint16{200, 204, 301, 399} {
+		if !sc.Check(code) {
+			t.Errorf("Check(%d) = false, want true", code)
+		}
+	}
+	for _, code := range []uint16{100, 400, 500} {
+		if sc.Check(code) {
+			t.Errorf("Check(%d) = true, want false", code)
+		}
+	}
+}
+
+func TestStatusCheckerRanges(t *testing.T) {
+	sc, err := NewStatusChecker("200/204/301-302/400-499")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	healthy := []uint16{200, 204, 301, 302, 400, 450, 499}
+	for _, code := range healthy {
+		if !sc.Check(code) {
+			t.Errorf("Check(%d) = false, want true", code)
+		}
+	}
+
+	unhealthy := []uint16{199, 201, 300, 303, 399, 500}
+	for _, code := range unhealthy {
+		if sc.Check(code) {
+			t.Errorf("Check(%d) = true, want false", code)
+		}
+	}
+}
+
+func TestStatusCheckerMergesOverlappingRanges(t *testing.T) {
+	sc, err := NewStatusChecker("200-204/202-206/500")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(sc.ranges) != 2 {
+		t.Fatalf("expected overlapping 200-204 and 202-206 to merge into one range, got %d ranges: %+v", len(sc.ranges), sc.ranges)
+	}
+
+	for _, code := range []uint16{200, 203, 206, 500} {
+		if !sc.Check(code) {
+			t.Errorf("Check(%d) = false, want true", code)
+		}
+	}
+	if sc.Check(207) {
+		t.Errorf("Check(207) = true, want false")
+	}
+}
+
+func TestStatusCheckerInvalidExpression(t *testing.T) {
+	cases := []string{"abc", "300-200", "200-", "-200"}
+	for _, expr := range cases {
+		if _, err := NewStatusChecker(expr); err == nil {
+			t.Errorf("NewStatusChecker(%q) succeeded, want error", expr)
+		}
+	}
+}
+
+func TestNewHealthCheckDefaultsTestURLFromExpectedStatus(t *testing.T) {
+	hc, err := newHealthCheck(&healthCheckSchema{
+		URL:            "http://www.gstatic.com/generate_204",
+		ExpectedStatus: "200-299",
+	})
+	if err != nil {
+		t.Fatalf("newHealthCheck error: %v", err)
+	}
+
+	if hc.testURL != "http://www.gstatic.com/generate_204" {
+		t.Errorf("testURL = %q, want it defaulted to url since test-url was unset", hc.testURL)
+	}
+}
+
+func TestNewHealthCheckLeavesTestURLEmptyWithoutExpectedStatus(t *testing.T) {
+	hc, err := newHealthCheck(&healthCheckSchema{URL: "http://www.gstatic.com/generate_204"})
+	if err != nil {
+		t.Fatalf("newHealthCheck error: %v", err)
+	}
+
+	if hc.testURL != "" {
+		t.Errorf("testURL = %q, want empty when neither test-url nor expected-status is set", hc.testURL)
+	}
+}
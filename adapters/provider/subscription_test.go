@@ -0,0 +1,57 @@
+package provider
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestParseSubscriptionInfo(t *testing.T) {
+	header := http.Header{}
+	header.Set("subscription-userinfo", "upload=1234; download=5678; total=10737418240; expire=1654041600")
+
+	info := parseSubscriptionInfo(header)
+	if info == nil {
+		t.Fatal("parseSubscriptionInfo returned nil for a present header")
+	}
+	if info.Upload != 1234 || info.Download != 5678 || info.Total != 10737418240 || info.Expire != 1654041600 {
+		t.Errorf("parsed info = %+v, want upload=1234 download=5678 total=10737418240 expire=1654041600", info)
+	}
+}
+
+func TestParseSubscriptionInfoMissing(t *testing.T) {
+	if info := parseSubscriptionInfo(http.Header{}); info != nil {
+		t.Errorf("parseSubscriptionInfo() = %+v, want nil for an absent header", info)
+	}
+}
+
+func TestParseSubscriptionInfoIgnoresUnknownFields(t *testing.T) {
+	header := http.Header{}
+	header.Set("subscription-userinfo", "upload=1; download=2; total=3; expire=4; bonus=99")
+
+	info := parseSubscriptionInfo(header)
+	if info == nil {
+		t.Fatal("parseSubscriptionInfo returned nil")
+	}
+	if info.Upload != 1 || info.Download != 2 || info.Total != 3 || info.Expire != 4 {
+		t.Errorf("parsed info = %+v, want upload=1 download=2 total=3 expire=4", info)
+	}
+}
+
+func TestSubscriptionInfoOverQuota(t *testing.T) {
+	info := &SubscriptionInfo{Upload: 60, Download: 0, Total: 100}
+
+	if !info.overQuota(0.5) {
+		t.Errorf("overQuota(0.5) = false, want true for 60/100 used")
+	}
+	if info.overQuota(0.7) {
+		t.Errorf("overQuota(0.7) = true, want false for 60/100 used")
+	}
+	if info.overQuota(0) {
+		t.Errorf("overQuota(0) = true, want false: ratio <= 0 disables the check")
+	}
+
+	var nilInfo *SubscriptionInfo
+	if nilInfo.overQuota(0.1) {
+		t.Errorf("overQuota on a nil *SubscriptionInfo must not panic or report over quota")
+	}
+}
@@ -0,0 +1,54 @@
+package provider
+
+import (
+	"fmt"
+	"regexp"
+
+	C "github.com/Dreamacro/clash/constant"
+)
+
+func compileFilter(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile(expr)
+}
+
+// UseProviders resolves a proxy group's `use:` entries into the
+// ProxyProviders it should read from, applying its own `filter` (if any) on
+// top of each named provider's proxies. Compiling filterExpr here, rather
+// than at first use, means an invalid regex fails at config-load time.
+// Wrapping in filteredProvider rather than re-pulling/re-parsing lets one
+// ProxySetProvider back several groups that each request a different
+// filtered view.
+func UseProviders(use []string, filterExpr string, providers map[string]ProxyProvider) ([]ProxyProvider, error) {
+	filterRegx, err := compileFilter(filterExpr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter: %w", err)
+	}
+
+	out := make([]ProxyProvider, 0, len(use))
+	for _, name := range use {
+		p, ok := providers[name]
+		if !ok {
+			return nil, fmt.Errorf("proxy provider %s not found", name)
+		}
+		if filterRegx != nil {
+			p = &filteredProvider{ProxyProvider: p, re: filterRegx}
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// filteredProvider narrows a ProxyProvider's Proxies() to the subset
+// matching re, via the provider's own ProxiesWithFilter, without disturbing
+// its underlying fetch/parse/health-check state.
+type filteredProvider struct {
+	ProxyProvider
+	re *regexp.Regexp
+}
+
+func (f *filteredProvider) Proxies() []C.Proxy {
+	return f.ProxyProvider.ProxiesWithFilter(f.re)
+}
@@ -0,0 +1,88 @@
+package provider
+
+import (
+	"regexp"
+	"testing"
+
+	C "github.com/Dreamacro/clash/constant"
+)
+
+type fakeProvider struct {
+	name          string
+	unhealthy     bool
+	lastFilter    *regexp.Regexp
+	proxiesCalled bool
+}
+
+func (f *fakeProvider) Name() string             { return f.name }
+func (f *fakeProvider) VehicleType() VehicleType { return Compatible }
+func (f *fakeProvider) Type() ProviderType       { return Proxy }
+func (f *fakeProvider) Initial() error           { return nil }
+func (f *fakeProvider) Reload() error            { return nil }
+func (f *fakeProvider) Destroy() error           { return nil }
+func (f *fakeProvider) Proxies() []C.Proxy       { f.proxiesCalled = true; return nil }
+func (f *fakeProvider) HealthCheck()             {}
+func (f *fakeProvider) Update() error            { return nil }
+func (f *fakeProvider) Unhealthy() bool          { return f.unhealthy }
+func (f *fakeProvider) ProxiesWithFilter(re *regexp.Regexp) []C.Proxy {
+	f.lastFilter = re
+	return nil
+}
+
+func TestUseProvidersResolvesByName(t *testing.T) {
+	hk := &fakeProvider{name: "hk"}
+	us := &fakeProvider{name: "us"}
+	providers := map[string]ProxyProvider{"hk": hk, "us": us}
+
+	resolved, err := UseProviders([]string{"hk", "us"}, "", providers)
+	if err != nil {
+		t.Fatalf("UseProviders error: %v", err)
+	}
+	if len(resolved) != 2 || resolved[0].Name() != "hk" || resolved[1].Name() != "us" {
+		t.Errorf("UseProviders = %v, want [hk us] in order", resolved)
+	}
+}
+
+func TestUseProvidersUnknownName(t *testing.T) {
+	if _, err := UseProviders([]string{"missing"}, "", map[string]ProxyProvider{}); err == nil {
+		t.Error("UseProviders with an unknown provider name succeeded, want an error")
+	}
+}
+
+func TestUseProvidersInvalidFilter(t *testing.T) {
+	hk := &fakeProvider{name: "hk"}
+	if _, err := UseProviders([]string{"hk"}, "(", map[string]ProxyProvider{"hk": hk}); err == nil {
+		t.Error("UseProviders with an invalid filter regex succeeded, want an error")
+	}
+}
+
+func TestUseProvidersAppliesFilterViaProxiesWithFilter(t *testing.T) {
+	hk := &fakeProvider{name: "hk"}
+	providers := map[string]ProxyProvider{"hk": hk}
+
+	resolved, err := UseProviders([]string{"hk"}, "HK|Hong Kong", providers)
+	if err != nil {
+		t.Fatalf("UseProviders error: %v", err)
+	}
+
+	resolved[0].Proxies()
+	if hk.lastFilter == nil || hk.lastFilter.String() != "HK|Hong Kong" {
+		t.Errorf("filtered provider's Proxies() didn't forward the compiled filter to ProxiesWithFilter, got %v", hk.lastFilter)
+	}
+}
+
+func TestProxiesFromProvidersSkipsUnhealthy(t *testing.T) {
+	healthy := &fakeProvider{name: "healthy"}
+	over := &fakeProvider{name: "over-quota", unhealthy: true}
+
+	// Neither fakeProvider returns any proxies, so this checks whose
+	// Proxies() is read rather than the aggregated list itself.
+	_ = ProxiesFromProviders([]ProxyProvider{healthy, over})
+
+	if !healthy.proxiesCalled {
+		t.Error("ProxiesFromProviders didn't read the healthy provider's proxies")
+	}
+	if over.proxiesCalled {
+		t.Error("ProxiesFromProviders read the unhealthy provider's proxies, want it skipped")
+	}
+}
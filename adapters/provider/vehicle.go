@@ -0,0 +1,101 @@
+package provider
+
+import (
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// VehicleType is the protocol a Vehicle uses to obtain a provider's data.
+type VehicleType int
+
+// Vehicle Type
+const (
+	File VehicleType = iota
+	HTTP
+	Compatible
+)
+
+func (v VehicleType) String() string {
+	switch v {
+	case File:
+		return "File"
+	case HTTP:
+		return "HTTP"
+	case Compatible:
+		return "Compatible"
+	default:
+		return "Unknown"
+	}
+}
+
+// Vehicle fetches the raw bytes of a provider's data (proxy list, rules, ...)
+// along with the response headers, and reports the local path it is cached
+// at. Headers let a caller such as ProxySetProvider read subscription
+// metadata (e.g. `subscription-userinfo`) that some providers ship alongside
+// the YAML body.
+type Vehicle interface {
+	Read() ([]byte, http.Header, error)
+	Path() string
+	Type() VehicleType
+}
+
+var vehicleClient = &http.Client{Timeout: 20 * time.Second}
+
+// FileVehicle reads a provider straight from a local file. Files never carry
+// subscription headers.
+type FileVehicle struct {
+	path string
+}
+
+func (f *FileVehicle) Type() VehicleType {
+	return File
+}
+
+func (f *FileVehicle) Path() string {
+	return f.path
+}
+
+func (f *FileVehicle) Read() ([]byte, http.Header, error) {
+	buf, err := ioutil.ReadFile(f.path)
+	return buf, nil, err
+}
+
+// NewFileVehicle creates a FileVehicle rooted at path.
+func NewFileVehicle(path string) *FileVehicle {
+	return &FileVehicle{path: path}
+}
+
+// HTTPVehicle fetches a provider over HTTP(S) and caches it at path.
+type HTTPVehicle struct {
+	url  string
+	path string
+}
+
+func (h *HTTPVehicle) Type() VehicleType {
+	return HTTP
+}
+
+func (h *HTTPVehicle) Path() string {
+	return h.path
+}
+
+func (h *HTTPVehicle) Read() ([]byte, http.Header, error) {
+	resp, err := vehicleClient.Get(h.url)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	buf, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return buf, resp.Header, nil
+}
+
+// NewHTTPVehicle creates an HTTPVehicle that fetches url and caches it at path.
+func NewHTTPVehicle(url, path string) *HTTPVehicle {
+	return &HTTPVehicle{url: url, path: path}
+}
@@ -0,0 +1,110 @@
+package vmess
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Atyp is the vmess destination address type.
+type Atyp byte
+
+const (
+	AtypIPv4       Atyp = 1
+	AtypDomainName Atyp = 2
+	AtypIPv6       Atyp = 3
+)
+
+// DstAddr is the address a vmess request targets.
+type DstAddr struct {
+	UDP      bool
+	AddrType byte
+	Addr     []byte
+	Port     uint
+}
+
+// Config configures a Client's server, cipher and transport.
+type Config struct {
+	UUID             string
+	AlterID          uint16
+	Security         string
+	TLS              bool
+	HostName         string
+	Port             string
+	NetWork          string
+	WebSocketPath    string
+	WebSocketHeaders map[string]string
+	GrpcServiceName  string
+	GrpcMode         string
+	H2Path           string
+	H2Host           []string
+	SkipCertVerify   bool
+	SessionCache     tls.ClientSessionCache
+}
+
+// Client dials the vmess protocol over whichever transport Config.NetWork
+// selects (plain TCP, WebSocket, gRPC or H2).
+type Client struct {
+	config Config
+
+	grpcConn *grpcClientConn
+}
+
+// NewClient validates config and returns a Client ready to wrap dialed
+// connections via New.
+func NewClient(config Config) (*Client, error) {
+	if config.UUID == "" {
+		return nil, errors.New("vmess: empty uuid")
+	}
+	if config.AlterID != 0 {
+		return nil, fmt.Errorf("vmess: alterId %d requires the legacy MD5 protocol, which is not implemented; set alterId: 0 to use AEAD", config.AlterID)
+	}
+
+	switch config.NetWork {
+	case "", "tcp", "ws", "grpc", "h2":
+	default:
+		return nil, fmt.Errorf("vmess: unsupported network %q", config.NetWork)
+	}
+
+	return &Client{config: config}, nil
+}
+
+// New upgrades conn, an already-dialed TCP connection to the vmess server,
+// into the configured transport stream and then authenticates dst as a
+// vmess request over it. The returned net.Conn's Read/Write carry dst's
+// decrypted/encrypted payload stream exactly as they would over a plain
+// TCP connection to dst.
+func (c *Client) New(conn net.Conn, dst *DstAddr) (net.Conn, error) {
+	if c.config.TLS {
+		tlsConn := tls.Client(conn, &tls.Config{
+			ServerName:         c.config.HostName,
+			InsecureSkipVerify: c.config.SkipCertVerify,
+			ClientSessionCache: c.config.SessionCache,
+			NextProtos:         []string{"h2"},
+		})
+		if err := tlsConn.HandshakeContext(context.Background()); err != nil {
+			return nil, fmt.Errorf("vmess: tls handshake error: %w", err)
+		}
+		conn = tlsConn
+	}
+
+	var err error
+	switch c.config.NetWork {
+	case "ws":
+		conn, err = c.newWsStream(conn)
+	case "grpc":
+		conn, err = c.newGrpcStream(conn)
+	case "h2":
+		conn, err = c.newH2Stream(conn)
+	case "", "tcp":
+	default:
+		return nil, fmt.Errorf("vmess: unsupported network %q", c.config.NetWork)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newVmessConn(conn, c.config, dst)
+}
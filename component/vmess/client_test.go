@@ -0,0 +1,23 @@
+package vmess
+
+import "testing"
+
+func TestNewClientRejectsNonZeroAlterID(t *testing.T) {
+	_, err := NewClient(Config{
+		UUID:    "b831381d-6324-4d53-ad4f-8cda48b30811",
+		AlterID: 64,
+	})
+	if err == nil {
+		t.Error("NewClient with a non-zero alterId succeeded, want an error since only AEAD (alterId: 0) is implemented")
+	}
+}
+
+func TestNewClientAcceptsZeroAlterID(t *testing.T) {
+	_, err := NewClient(Config{
+		UUID:    "b831381d-6324-4d53-ad4f-8cda48b30811",
+		AlterID: 0,
+	})
+	if err != nil {
+		t.Errorf("NewClient with alterId 0 failed: %v", err)
+	}
+}
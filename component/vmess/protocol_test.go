@@ -0,0 +1,145 @@
+package vmess
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestEncodeDecodeRequestHeaderRoundTrip(t *testing.T) {
+	id, err := parseUUID("b831381d-6324-4d53-ad4f-8cda48b30811")
+	if err != nil {
+		t.Fatalf("parseUUID error: %v", err)
+	}
+	key := cmdKey(id)
+
+	dst := &DstAddr{
+		AddrType: byte(AtypDomainName),
+		Addr:     append([]byte{byte(len("example.com"))}, []byte("example.com")...),
+		Port:     443,
+	}
+
+	bodyKey := bytes.Repeat([]byte{0x11}, 16)
+	bodyIV := bytes.Repeat([]byte{0x22}, 16)
+
+	wire, err := encodeRequestHeader(key, securityChacha20Poly1305, 0x42, bodyKey, bodyIV, dst)
+	if err != nil {
+		t.Fatalf("encodeRequestHeader error: %v", err)
+	}
+
+	// A server must discover which account a request belongs to from the
+	// wire authID alone, searching its registered accounts - it is never
+	// handed the encoding key directly the way this test could hand it one.
+	otherID, _ := parseUUID("00000000-0000-0000-0000-000000000000")
+	candidateKeys := [][]byte{cmdKey(otherID), key}
+
+	decoded, err := decodeRequestHeader(candidateKeys, wire)
+	if err != nil {
+		t.Fatalf("decodeRequestHeader error: %v", err)
+	}
+
+	if decoded.Security != securityChacha20Poly1305 {
+		t.Errorf("Security = %d, want %d", decoded.Security, securityChacha20Poly1305)
+	}
+	if decoded.RespV != 0x42 {
+		t.Errorf("RespV = %#x, want 0x42", decoded.RespV)
+	}
+	if !bytes.Equal(decoded.BodyKey, bodyKey) {
+		t.Errorf("BodyKey = %x, want %x", decoded.BodyKey, bodyKey)
+	}
+	if !bytes.Equal(decoded.BodyIV, bodyIV) {
+		t.Errorf("BodyIV = %x, want %x", decoded.BodyIV, bodyIV)
+	}
+	if decoded.Dst.UDP {
+		t.Errorf("Dst.UDP = true, want false")
+	}
+	if decoded.Dst.Port != 443 {
+		t.Errorf("Dst.Port = %d, want 443", decoded.Dst.Port)
+	}
+	if !bytes.Equal(decoded.Dst.Addr, dst.Addr) {
+		t.Errorf("Dst.Addr = %x, want %x", decoded.Dst.Addr, dst.Addr)
+	}
+}
+
+func TestDecodeRequestHeaderRejectsWrongKey(t *testing.T) {
+	idA, _ := parseUUID("b831381d-6324-4d53-ad4f-8cda48b30811")
+	idB, _ := parseUUID("00000000-0000-0000-0000-000000000000")
+
+	dst := &DstAddr{AddrType: byte(AtypIPv4), Addr: []byte{1, 2, 3, 4}, Port: 80}
+	wire, err := encodeRequestHeader(cmdKey(idA), securityAES128GCM, 1, bytes.Repeat([]byte{1}, 16), bytes.Repeat([]byte{2}, 16), dst)
+	if err != nil {
+		t.Fatalf("encodeRequestHeader error: %v", err)
+	}
+
+	// idA's own request, checked against a server that only knows idB: no
+	// candidate key should match idA's authID.
+	if _, err := decodeRequestHeader([][]byte{cmdKey(idB)}, wire); err == nil {
+		t.Error("decodeRequestHeader with only the wrong account registered succeeded, want an error")
+	}
+}
+
+func TestFindAccountKeyDiscoversAccountFromAuthIDAlone(t *testing.T) {
+	idA, _ := parseUUID("b831381d-6324-4d53-ad4f-8cda48b30811")
+	idB, _ := parseUUID("00000000-0000-0000-0000-000000000000")
+	keyA, keyB := cmdKey(idA), cmdKey(idB)
+
+	authID := computeAuthID(keyA, time.Now().Unix())
+
+	got, err := findAccountKey([][]byte{keyB, keyA}, authID)
+	if err != nil {
+		t.Fatalf("findAccountKey error: %v", err)
+	}
+	if !bytes.Equal(got, keyA) {
+		t.Errorf("findAccountKey resolved the wrong account's key")
+	}
+
+	if _, err := findAccountKey([][]byte{keyB}, authID); err == nil {
+		t.Error("findAccountKey matched an authID against an unregistered account, want an error")
+	}
+}
+
+func TestAEADBodyRoundTrip(t *testing.T) {
+	for _, security := range []byte{securityAES128GCM, securityChacha20Poly1305} {
+		key := bytes.Repeat([]byte{0x07}, 16)
+		iv := bytes.Repeat([]byte{0x09}, 16)
+
+		client, server := net.Pipe()
+		defer client.Close()
+		defer server.Close()
+
+		writer, err := newBodyWriter(client, security, key, iv)
+		if err != nil {
+			t.Fatalf("newBodyWriter error: %v", err)
+		}
+		reader, err := newBodyReader(server, security, key, iv)
+		if err != nil {
+			t.Fatalf("newBodyReader error: %v", err)
+		}
+
+		want := []byte("hello vmess body")
+		go func() {
+			_, _ = writer.Write(want)
+		}()
+
+		got := make([]byte, len(want))
+		if _, err := readFull(reader, got); err != nil {
+			t.Fatalf("read error: %v", err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("security %d: got %q, want %q", security, got, want)
+		}
+	}
+}
+
+func readFull(r interface{ Read([]byte) (int, error) }, buf []byte) (int, error) {
+	total := 0
+	for total < len(buf) {
+		n, err := r.Read(buf[total:])
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
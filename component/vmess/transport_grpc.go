@@ -0,0 +1,218 @@
+package vmess
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// grpcClientConn is the shared *http2.ClientConn a vmess Client multiplexes
+// its gRPC streams over, so repeated dials to the same server reuse one
+// underlying HTTP/2 connection.
+type grpcClientConn struct {
+	raw  net.Conn
+	conn *http2.ClientConn
+}
+
+// newGrpcStream opens a bidirectional gRPC stream to /<service-name>/Tun
+// over conn, framing every vmess payload as a length-prefixed protobuf
+// Hunk{bytes data = 1} message, per the single-stream gun transport
+// popularised by Xray/v2ray-core. Multi mode is not implemented here.
+func (c *Client) newGrpcStream(conn net.Conn) (net.Conn, error) {
+	cc, err := c.grpcClientConn(conn)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceName := c.config.GrpcServiceName
+	if serviceName == "" {
+		serviceName = "GunService"
+	}
+	path := fmt.Sprintf("/%s/Tun", serviceName)
+
+	pr, pw := io.Pipe()
+	req, err := http.NewRequest(http.MethodPost, "https://"+c.config.HostName+path, pr)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("content-type", "application/grpc")
+	req.Header.Set("te", "trailers")
+
+	resp, err := roundTrip(cc, req, pw)
+	if err != nil {
+		return nil, fmt.Errorf("vmess: grpc stream error: %w", err)
+	}
+
+	return &grpcStreamConn{body: resp.Body, writer: pw, local: conn.LocalAddr(), remote: conn.RemoteAddr()}, nil
+}
+
+// grpcClientConn returns the shared *http2.ClientConn for conn's underlying
+// server, dialing a fresh one if none exists yet or the previous one can no
+// longer take requests.
+func (c *Client) grpcClientConn(conn net.Conn) (*http2.ClientConn, error) {
+	if c.grpcConn != nil && c.grpcConn.conn.CanTakeNewRequest() {
+		// conn is a freshly dialed socket the caller no longer needs once we
+		// hand back the shared ClientConn instead; close it so dialing a new
+		// stream on an already-multiplexed server doesn't leak a connection.
+		_ = conn.Close()
+		return c.grpcConn.conn, nil
+	}
+
+	t := &http2.Transport{AllowHTTP: true}
+	cc, err := t.NewClientConn(conn)
+	if err != nil {
+		return nil, fmt.Errorf("vmess: grpc h2 setup error: %w", err)
+	}
+
+	if c.grpcConn != nil {
+		// The previous shared conn is stale (CanTakeNewRequest is false) and
+		// about to be replaced; close its socket or it and whatever
+		// goroutines http2.ClientConn still holds for it leak forever.
+		_ = c.grpcConn.raw.Close()
+	}
+
+	c.grpcConn = &grpcClientConn{raw: conn, conn: cc}
+	return cc, nil
+}
+
+// roundTrip issues req over cc and waits for the response headers, bounding
+// the wait so a server that never answers can't hang New forever.
+func roundTrip(cc *http2.ClientConn, req *http.Request, body io.Closer) (*http.Response, error) {
+	type result struct {
+		resp *http.Response
+		err  error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		resp, err := cc.RoundTrip(req)
+		done <- result{resp, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			body.Close()
+		}
+		return r.resp, r.err
+	case <-time.After(10 * time.Second):
+		body.Close()
+		return nil, fmt.Errorf("stream setup timeout")
+	}
+}
+
+// grpcStreamConn adapts a single gRPC bidi-stream (an HTTP/2 request body we
+// write to and a response body we read from) to a net.Conn, de-framing and
+// framing Hunk messages on Read/Write respectively.
+type grpcStreamConn struct {
+	body   io.ReadCloser
+	writer *io.PipeWriter
+	local  net.Addr
+	remote net.Addr
+
+	pending []byte
+}
+
+func (g *grpcStreamConn) Read(b []byte) (int, error) {
+	for len(g.pending) == 0 {
+		hunk, err := readHunk(g.body)
+		if err != nil {
+			return 0, err
+		}
+		g.pending = hunk
+	}
+
+	n := copy(b, g.pending)
+	g.pending = g.pending[n:]
+	return n, nil
+}
+
+func (g *grpcStreamConn) Write(b []byte) (int, error) {
+	if err := writeHunk(g.writer, b); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (g *grpcStreamConn) Close() error {
+	_ = g.writer.Close()
+	return g.body.Close()
+}
+
+func (g *grpcStreamConn) LocalAddr() net.Addr                { return g.local }
+func (g *grpcStreamConn) RemoteAddr() net.Addr               { return g.remote }
+func (g *grpcStreamConn) SetDeadline(t time.Time) error      { return nil }
+func (g *grpcStreamConn) SetReadDeadline(t time.Time) error  { return nil }
+func (g *grpcStreamConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// readHunk reads one gRPC-framed protobuf Hunk{bytes data = 1} message: a
+// 5-byte gRPC frame header (a compression flag byte plus a 4-byte
+// big-endian length) followed by the message itself.
+func readHunk(r io.Reader) ([]byte, error) {
+	var frameHeader [5]byte
+	if _, err := io.ReadFull(r, frameHeader[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(frameHeader[1:])
+	if size > maxWireFrame {
+		return nil, fmt.Errorf("vmess: grpc frame too large (%d bytes)", size)
+	}
+
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+
+	return unmarshalHunk(msg)
+}
+
+func writeHunk(w io.Writer, data []byte) error {
+	msg := marshalHunk(data)
+
+	frame := make([]byte, 5+len(msg))
+	frame[0] = 0 // not compressed
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	copy(frame[5:], msg)
+
+	_, err := w.Write(frame)
+	return err
+}
+
+// marshalHunk encodes data as the protobuf message Hunk{bytes data = 1}: a
+// single length-delimited field (field number 1, wire type 2).
+func marshalHunk(data []byte) []byte {
+	buf := make([]byte, 0, len(data)+10)
+	buf = appendVarint(buf, uint64(1)<<3|2)
+	buf = appendVarint(buf, uint64(len(data)))
+	buf = append(buf, data...)
+	return buf
+}
+
+func unmarshalHunk(msg []byte) ([]byte, error) {
+	tag, n := binary.Uvarint(msg)
+	if n <= 0 || tag != uint64(1)<<3|2 {
+		return nil, fmt.Errorf("vmess: malformed Hunk frame")
+	}
+	msg = msg[n:]
+
+	size, n := binary.Uvarint(msg)
+	if n <= 0 || uint64(len(msg)-n) < size {
+		return nil, fmt.Errorf("vmess: malformed Hunk frame")
+	}
+	msg = msg[n:]
+
+	return msg[:size], nil
+}
+
+func appendVarint(buf []byte, v uint64) []byte {
+	for v >= 0x80 {
+		buf = append(buf, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(buf, byte(v))
+}
@@ -0,0 +1,65 @@
+package vmess
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestMarshalUnmarshalHunkRoundTrip(t *testing.T) {
+	cases := [][]byte{
+		nil,
+		{},
+		[]byte("short"),
+		bytes.Repeat([]byte{0xAB}, 127), // crosses the 1-byte/2-byte varint boundary
+		bytes.Repeat([]byte{0xCD}, 128),
+		bytes.Repeat([]byte{0xEF}, 16384), // crosses the 2-byte/3-byte varint boundary
+	}
+
+	for _, data := range cases {
+		msg := marshalHunk(data)
+
+		got, err := unmarshalHunk(msg)
+		if err != nil {
+			t.Errorf("unmarshalHunk(marshalHunk(%d bytes)) error: %v", len(data), err)
+			continue
+		}
+		if !bytes.Equal(got, data) && !(len(got) == 0 && len(data) == 0) {
+			t.Errorf("unmarshalHunk(marshalHunk(%d bytes)) = %d bytes, want %d bytes round-tripped", len(data), len(got), len(data))
+		}
+	}
+}
+
+func TestUnmarshalHunkRejectsMalformed(t *testing.T) {
+	cases := map[string][]byte{
+		"empty":       {},
+		"wrong tag":   {0x08, 0x01, 0x02},
+		"truncated":   marshalHunk([]byte("hello"))[:3],
+		"length lies": append(appendVarint(nil, uint64(1)<<3|2), appendVarint(nil, 100)...),
+	}
+
+	for name, msg := range cases {
+		if _, err := unmarshalHunk(msg); err == nil {
+			t.Errorf("%s: unmarshalHunk succeeded, want error", name)
+		}
+	}
+}
+
+func TestAppendVarintRoundTrip(t *testing.T) {
+	for _, v := range []uint64{0, 1, 127, 128, 300, 16383, 16384, 1 << 20} {
+		buf := appendVarint(nil, v)
+
+		var got uint64
+		for i, shift := 0, uint(0); ; i, shift = i+1, shift+7 {
+			got |= uint64(buf[i]&0x7F) << shift
+			if buf[i] < 0x80 {
+				if i != len(buf)-1 {
+					t.Errorf("appendVarint(%d) left trailing bytes: %v", v, buf)
+				}
+				break
+			}
+		}
+		if got != v {
+			t.Errorf("appendVarint(%d) round-tripped to %d", v, got)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package vmess
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// newH2Stream wraps conn in an http2.Transport and issues a single
+// long-lived POST, using its chunked request/response bodies as a duplex
+// byte stream for vmess payloads.
+func (c *Client) newH2Stream(conn net.Conn) (net.Conn, error) {
+	t := &http2.Transport{AllowHTTP: true}
+	cc, err := t.NewClientConn(conn)
+	if err != nil {
+		return nil, fmt.Errorf("vmess: h2 setup error: %w", err)
+	}
+
+	host := c.config.HostName
+	if len(c.config.H2Host) > 0 {
+		host = c.config.H2Host[0]
+	}
+	path := c.config.H2Path
+	if path == "" {
+		path = "/"
+	}
+
+	pr, pw := io.Pipe()
+	req, err := http.NewRequest(http.MethodPost, "https://"+host+path, pr)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("content-type", "application/octet-stream")
+
+	resp, err := roundTrip(cc, req, pw)
+	if err != nil {
+		return nil, fmt.Errorf("vmess: h2 stream error: %w", err)
+	}
+
+	return &h2StreamConn{body: resp.Body, writer: pw, local: conn.LocalAddr(), remote: conn.RemoteAddr()}, nil
+}
+
+// h2StreamConn adapts a single h2 request/response body pair to a net.Conn,
+// used as a duplex byte stream for vmess payloads.
+type h2StreamConn struct {
+	body   io.ReadCloser
+	writer *io.PipeWriter
+	local  net.Addr
+	remote net.Addr
+}
+
+func (h *h2StreamConn) Read(b []byte) (int, error)  { return h.body.Read(b) }
+func (h *h2StreamConn) Write(b []byte) (int, error) { return h.writer.Write(b) }
+
+func (h *h2StreamConn) Close() error {
+	_ = h.writer.Close()
+	return h.body.Close()
+}
+
+func (h *h2StreamConn) LocalAddr() net.Addr                { return h.local }
+func (h *h2StreamConn) RemoteAddr() net.Addr               { return h.remote }
+func (h *h2StreamConn) SetDeadline(t time.Time) error      { return nil }
+func (h *h2StreamConn) SetReadDeadline(t time.Time) error  { return nil }
+func (h *h2StreamConn) SetWriteDeadline(t time.Time) error { return nil }
@@ -0,0 +1,163 @@
+package vmess
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+const wsOpBinary = 0x2
+
+// newWsStream upgrades conn to a WebSocket connection at config.WebSocketPath
+// (sending config.WebSocketHeaders with the handshake) and wraps it as a
+// net.Conn that frames every Read/Write as a binary WebSocket message, per
+// RFC 6455.
+func (c *Client) newWsStream(conn net.Conn) (net.Conn, error) {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("vmess: rand error: %w", err)
+	}
+
+	path := c.config.WebSocketPath
+	if path == "" {
+		path = "/"
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "http://"+c.config.HostName+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Host", c.config.HostName)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", base64.StdEncoding.EncodeToString(key))
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	for k, v := range c.config.WebSocketHeaders {
+		req.Header.Set(k, v)
+	}
+
+	if err := conn.SetDeadline(time.Now().Add(10 * time.Second)); err != nil {
+		return nil, err
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("vmess: ws handshake write error: %w", err)
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, fmt.Errorf("vmess: ws handshake read error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, fmt.Errorf("vmess: ws handshake error: unexpected status %s", resp.Status)
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+
+	return &wsConn{Conn: conn, br: br}, nil
+}
+
+// wsConn adapts a single WebSocket connection to a net.Conn, framing every
+// Write as one masked binary message (required of a client per RFC 6455)
+// and de-framing binary messages on Read.
+type wsConn struct {
+	net.Conn
+	br *bufio.Reader
+
+	pending []byte
+}
+
+func (w *wsConn) Write(b []byte) (int, error) {
+	mask := make([]byte, 4)
+	if _, err := rand.Read(mask); err != nil {
+		return 0, err
+	}
+
+	masked := make([]byte, len(b))
+	for i, c := range b {
+		masked[i] = c ^ mask[i%4]
+	}
+
+	var header bytes.Buffer
+	header.WriteByte(0x80 | wsOpBinary) // FIN + binary opcode
+
+	switch {
+	case len(b) < 126:
+		header.WriteByte(0x80 | byte(len(b)))
+	case len(b) <= 0xFFFF:
+		header.WriteByte(0x80 | 126)
+		_ = binary.Write(&header, binary.BigEndian, uint16(len(b)))
+	default:
+		header.WriteByte(0x80 | 127)
+		_ = binary.Write(&header, binary.BigEndian, uint64(len(b)))
+	}
+	header.Write(mask)
+
+	if _, err := w.Conn.Write(header.Bytes()); err != nil {
+		return 0, err
+	}
+	if _, err := w.Conn.Write(masked); err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+func (w *wsConn) Read(b []byte) (int, error) {
+	for len(w.pending) == 0 {
+		payload, err := w.readFrame()
+		if err != nil {
+			return 0, err
+		}
+		w.pending = payload
+	}
+
+	n := copy(b, w.pending)
+	w.pending = w.pending[n:]
+	return n, nil
+}
+
+// readFrame reads a single (unmasked, server-to-client) WebSocket frame and
+// returns its payload.
+func (w *wsConn) readFrame() ([]byte, error) {
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(w.br, head); err != nil {
+		return nil, err
+	}
+
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(w.br, ext); err != nil {
+			return nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err := io.ReadFull(w.br, ext); err != nil {
+			return nil, err
+		}
+		length = binary.BigEndian.Uint64(ext)
+	}
+	if length > maxWireFrame {
+		return nil, fmt.Errorf("vmess: ws frame too large (%d bytes)", length)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(w.br, payload); err != nil {
+		return nil, err
+	}
+
+	return payload, nil
+}
@@ -0,0 +1,57 @@
+package constant
+
+// AdapterType enumerates the proxy adapter/group types a C.Proxy can be, so
+// the REST API and dashboard can report a proxy's kind without depending on
+// the adapters package.
+type AdapterType int
+
+const (
+	Direct AdapterType = iota
+	Reject
+
+	Shadowsocks
+	Socks5
+	Http
+	Vmess
+	Trojan
+	Ssh
+
+	Relay
+	Selector
+	Fallback
+	URLTest
+	LoadBalance
+)
+
+func (at AdapterType) String() string {
+	switch at {
+	case Direct:
+		return "Direct"
+	case Reject:
+		return "Reject"
+	case Shadowsocks:
+		return "Shadowsocks"
+	case Socks5:
+		return "Socks5"
+	case Http:
+		return "Http"
+	case Vmess:
+		return "Vmess"
+	case Trojan:
+		return "Trojan"
+	case Ssh:
+		return "Ssh"
+	case Relay:
+		return "Relay"
+	case Selector:
+		return "Selector"
+	case Fallback:
+		return "Fallback"
+	case URLTest:
+		return "URLTest"
+	case LoadBalance:
+		return "LoadBalance"
+	default:
+		return "Unknown"
+	}
+}